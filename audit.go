@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// auditMaxBytes is the size at which the audit log is rotated aside.
+const auditMaxBytes = 10 * 1024 * 1024
+
+// AuditEntry is one newline-delimited JSON record in the audit log: a
+// command the agent ran, what it returned, and (when available) the prompt
+// and model that led to it.
+type AuditEntry struct {
+	Timestamp  string          `json:"timestamp"`
+	UserPrompt string          `json:"user_prompt,omitempty"`
+	Model      string          `json:"model,omitempty"`
+	Request    CommandRequest  `json:"request"`
+	Response   CommandResponse `json:"response"`
+}
+
+// AuditLogger records every command the agent runs to
+// ~/.mac-agent/audit.log, redacting anything that looks like a secret value
+// loaded from the environment.
+type AuditLogger struct {
+	path     string
+	redactor *regexp.Regexp
+}
+
+// NewAuditLogger opens the audit log at ~/.mac-agent/audit.log, building its
+// redaction patterns from the current process environment.
+func NewAuditLogger() (*AuditLogger, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("resolving home directory: %w", err)
+	}
+
+	dir := filepath.Join(home, ".mac-agent")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating mac-agent directory: %w", err)
+	}
+
+	return &AuditLogger{
+		path:     filepath.Join(dir, "audit.log"),
+		redactor: buildRedactor(os.Environ()),
+	}, nil
+}
+
+// buildRedactor compiles a regex matching the values of any environment
+// variable whose name looks like it holds a secret (API keys, tokens,
+// passwords loaded via loadEnvFile), so those values never reach the log
+// verbatim even if a command happens to echo them.
+func buildRedactor(environ []string) *regexp.Regexp {
+	var values []string
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || value == "" {
+			continue
+		}
+
+		upper := strings.ToUpper(name)
+		if strings.Contains(upper, "KEY") || strings.Contains(upper, "TOKEN") ||
+			strings.Contains(upper, "SECRET") || strings.Contains(upper, "PASSWORD") {
+			values = append(values, regexp.QuoteMeta(value))
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	return regexp.MustCompile(strings.Join(values, "|"))
+}
+
+func (al *AuditLogger) redact(s string) string {
+	if al.redactor == nil || s == "" {
+		return s
+	}
+	return al.redactor.ReplaceAllString(s, "[REDACTED]")
+}
+
+// redactRequest returns req with its command and args run through redact,
+// so a secret passed as a literal argument (e.g. an expanded $API_KEY) is
+// scrubbed the same as one that comes back in a command's output.
+func (al *AuditLogger) redactRequest(req CommandRequest) CommandRequest {
+	req.Command = al.redact(req.Command)
+	if len(req.Args) > 0 {
+		args := make([]string, len(req.Args))
+		for i, arg := range req.Args {
+			args[i] = al.redact(arg)
+		}
+		req.Args = args
+	}
+	return req
+}
+
+// Log appends one redacted audit entry and rotates the log first if it has
+// grown past auditMaxBytes.
+func (al *AuditLogger) Log(userPrompt, model string, req CommandRequest, resp CommandResponse) error {
+	if err := al.rotateIfNeeded(); err != nil {
+		return err
+	}
+
+	req = al.redactRequest(req)
+	resp.Output = al.redact(resp.Output)
+	resp.Error = al.redact(resp.Error)
+
+	entry := AuditEntry{
+		Timestamp:  time.Now().Format(time.RFC3339),
+		UserPrompt: al.redact(userPrompt),
+		Model:      model,
+		Request:    req,
+		Response:   resp,
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding audit entry: %w", err)
+	}
+
+	f, err := os.OpenFile(al.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func (al *AuditLogger) rotateIfNeeded() error {
+	info, err := os.Stat(al.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stat-ing audit log: %w", err)
+	}
+
+	if info.Size() < auditMaxBytes {
+		return nil
+	}
+
+	rotated := al.path + "." + time.Now().Format("20060102-150405")
+	return os.Rename(al.path, rotated)
+}
+
+// Tail returns the last n lines of the audit log.
+func (al *AuditLogger) Tail(n int) ([]string, error) {
+	lines, err := al.readLines()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
+
+// Grep returns every audit log line matching pattern.
+func (al *AuditLogger) Grep(pattern string) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	lines, err := al.readLines()
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if re.MatchString(line) {
+			matched = append(matched, line)
+		}
+	}
+	return matched, nil
+}
+
+// Export copies the current audit log to dest.
+func (al *AuditLogger) Export(dest string) error {
+	data, err := os.ReadFile(al.path)
+	if err != nil {
+		return fmt.Errorf("reading audit log: %w", err)
+	}
+
+	if err := os.WriteFile(dest, data, 0600); err != nil {
+		return fmt.Errorf("writing export: %w", err)
+	}
+	return nil
+}
+
+func (al *AuditLogger) readLines() ([]string, error) {
+	f, err := os.Open(al.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// conversationWindow bounds how many prior turns are fed back into each
+// prompt, so long-running sessions don't grow the prompt without bound.
+const conversationWindow = 20
+
+// maxToolResultChars truncates prior command output before it's replayed
+// into the prompt as a tool-result turn.
+const maxToolResultChars = 2000
+
+// ConversationTurn is a single message in a Conversation's history: a user
+// prompt, an assistant explanation, or a tool-result summarizing a command
+// that was run.
+type ConversationTurn struct {
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Conversation is the on-disk, per-session message history ProcessUserRequest
+// replays into every prompt so the agent has memory of what it already ran.
+type Conversation struct {
+	ID      string             `json:"id"`
+	Created string             `json:"created"`
+	Turns   []ConversationTurn `json:"turns"`
+
+	path string
+}
+
+// sessionsDir returns ~/.mac-agent/sessions, creating it if necessary.
+func sessionsDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving home directory: %w", err)
+	}
+
+	dir := filepath.Join(home, ".mac-agent", "sessions")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("creating sessions directory: %w", err)
+	}
+
+	return dir, nil
+}
+
+// newConversationID generates a sortable, collision-resistant session id.
+func newConversationID() (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("generating session id: %w", err)
+	}
+
+	return fmt.Sprintf("%s-%x", time.Now().Format("20060102-150405"), suffix), nil
+}
+
+// NewConversation creates and persists a fresh, empty session.
+func NewConversation() (*Conversation, error) {
+	id, err := newConversationID()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	conv := &Conversation{
+		ID:      id,
+		Created: time.Now().Format(time.RFC3339),
+		path:    filepath.Join(dir, id+".json"),
+	}
+
+	return conv, conv.Save()
+}
+
+// LoadConversation reads a previously saved session by id.
+func LoadConversation(id string) (*Conversation, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, id+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading session '%s': %w", id, err)
+	}
+
+	var conv Conversation
+	if err := json.Unmarshal(data, &conv); err != nil {
+		return nil, fmt.Errorf("parsing session '%s': %w", id, err)
+	}
+	conv.path = path
+
+	return &conv, nil
+}
+
+// ListConversations returns the ids of every saved session, oldest first.
+func ListConversations() ([]string, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading sessions directory: %w", err)
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Save writes the conversation back to its session file.
+func (c *Conversation) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding session '%s': %w", c.ID, err)
+	}
+
+	if err := os.WriteFile(c.path, data, 0600); err != nil {
+		return fmt.Errorf("writing session '%s': %w", c.ID, err)
+	}
+
+	return nil
+}
+
+func (c *Conversation) addTurn(role, content string) {
+	c.Turns = append(c.Turns, ConversationTurn{
+		Role:      role,
+		Content:   content,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// AddUserTurn records a user prompt.
+func (c *Conversation) AddUserTurn(content string) {
+	c.addTurn("user", content)
+}
+
+// AddAssistantTurn records the model's explanation for a turn.
+func (c *Conversation) AddAssistantTurn(content string) {
+	c.addTurn("assistant", content)
+}
+
+// AddToolResultTurn records a command the agent ran and its (truncated)
+// output, so future prompts can see what was already tried.
+func (c *Conversation) AddToolResultTurn(cmd CommandRequest, resp CommandResponse) {
+	output := resp.Output
+	if len(output) > maxToolResultChars {
+		output = output[:maxToolResultChars] + "... [truncated]"
+	}
+
+	status := "succeeded"
+	if !resp.Success {
+		status = "failed: " + resp.Error
+	}
+
+	content := fmt.Sprintf("Ran `%s %s` (%s)\n%s", cmd.Command, strings.Join(cmd.Args, " "), status, output)
+	c.addTurn("tool", content)
+}
+
+// Messages converts the windowed conversation history into the Message
+// slice a backend's Generate expects.
+func (c *Conversation) Messages() []Message {
+	turns := c.Turns
+	if len(turns) > conversationWindow {
+		turns = turns[len(turns)-conversationWindow:]
+	}
+
+	messages := make([]Message, 0, len(turns))
+	for _, t := range turns {
+		role := t.Role
+		if role == "tool" {
+			role = "system"
+		}
+		messages = append(messages, Message{Role: role, Content: t.Content})
+	}
+
+	return messages
+}
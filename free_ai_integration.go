@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,117 +16,206 @@ import (
 const SystemPrompt = `You are a helpful AI assistant that can execute commands on macOS systems. Your role is to:
 
 1. Understand user requests and translate them into appropriate system commands
-2. Only suggest safe, whitelisted commands
+2. Only call the whitelisted tools you are given
 3. Provide clear explanations of what you're doing
 4. Express confidence in your decisions
 
-Available commands: ls, pwd, whoami, date, uptime, ps, top, df, du, find, grep, cat, head, tail, wc, sort, uniq, echo, mkdir, rmdir, cp, mv, rm, chmod, chown, file, stat, which, whereis, system_profiler, sw_vers, defaults, launchctl, netstat, lsof, ifconfig, ping, nslookup, dig, curl, wget
-
 Safety rules:
 - Never suggest dangerous commands like 'sudo', 'rm -rf /', 'format', or 'dd'
 - Always use safe alternatives
 - Explain what each command does
-- Be specific about arguments and options
-
-Respond in valid JSON format with thoughts, commands array, explanation, and confidence level.`
+- Be specific about arguments and options`
 
+// FreeAIAgent pairs the local Agent (which actually runs commands) with an
+// LLMBackend (which decides which commands to run).
 type FreeAIAgent struct {
 	*Agent
-	serviceType string
-	baseURL     string
-	apiKey      string
-	model       string
+	serviceType  string
+	model        string
+	backend      LLMBackend
+	conversation *Conversation
 }
 
+// FreeAIResponse is the outward-facing result of a single ProcessUserRequest
+// call: the model's reasoning plus the commands it chose to run and their
+// results.
 type FreeAIResponse struct {
-	Thoughts     string              `json:"thoughts"`
-	Commands     []CommandRequest    `json:"commands"`
-	Explanation  string              `json:"explanation"`
-	Confidence   float64             `json:"confidence"`
-	Results      []CommandResponse   `json:"results,omitempty"`
-	Error        string              `json:"error,omitempty"`
+	Thoughts    string            `json:"thoughts"`
+	Commands    []CommandRequest  `json:"commands"`
+	Explanation string            `json:"explanation"`
+	Confidence  float64           `json:"confidence"`
+	Results     []CommandResponse `json:"results,omitempty"`
+	Usage       *Usage            `json:"usage,omitempty"`
+	Error       string            `json:"error,omitempty"`
 }
 
-func NewFreeAIAgent(serviceType, baseURL, apiKey, model string) *FreeAIAgent {
+func NewFreeAIAgent(serviceType, model string, backend LLMBackend) *FreeAIAgent {
 	return &FreeAIAgent{
-		Agent:       NewAgent(),
+		Agent:       newAgentFromEnv(),
 		serviceType: serviceType,
-		baseURL:     baseURL,
-		apiKey:      apiKey,
 		model:       model,
+		backend:     backend,
 	}
 }
 
 func NewOllamaAgent(model string) *FreeAIAgent {
-	return NewFreeAIAgent("ollama", "http://localhost:11434", "", model)
+	return NewFreeAIAgent("ollama", model, NewOllamaBackend("http://localhost:11434", model))
 }
 
 func NewHuggingFaceAgent(apiKey, model string) *FreeAIAgent {
-	return NewFreeAIAgent("huggingface", "https://api-inference.huggingface.co", apiKey, model)
+	return NewFreeAIAgent("huggingface", model, NewHuggingFaceBackend("https://api-inference.huggingface.co", apiKey, model))
 }
 
 func NewLocalAgent(baseURL, model string) *FreeAIAgent {
-	return NewFreeAIAgent("local", baseURL, "", model)
+	return NewFreeAIAgent("local", model, NewLocalBackend(baseURL, model))
+}
+
+func NewOpenAICompatibleAgent(baseURL, apiKey, model string) *FreeAIAgent {
+	return NewFreeAIAgent("openai", model, NewOpenAIBackend(baseURL, apiKey, model))
+}
+
+// AttachConversation makes fa replay conv's history into every prompt and
+// append new turns to it as requests are processed.
+func (fa *FreeAIAgent) AttachConversation(conv *Conversation) {
+	fa.conversation = conv
 }
 
 func (fa *FreeAIAgent) ProcessUserRequest(userMessage string) (*FreeAIResponse, error) {
+	messages, tools := fa.buildPrompt(userMessage)
+
+	result, err := fa.backend.Generate(context.Background(), messages, tools)
+	if err != nil {
+		return nil, err
+	}
+
+	return fa.dispatch(userMessage, result), nil
+}
+
+// ProcessUserRequestStream behaves like ProcessUserRequest but, when the
+// configured backend supports it, invokes onToken with each content
+// fragment as it arrives instead of blocking until generation finishes.
+// Backends without streaming support (e.g. Hugging Face) fall back to a
+// single onToken call with the full content.
+func (fa *FreeAIAgent) ProcessUserRequestStream(userMessage string, onToken func(string)) (*FreeAIResponse, error) {
+	streamingBackend, ok := fa.backend.(StreamingBackend)
+	if !ok {
+		response, err := fa.ProcessUserRequest(userMessage)
+		if err != nil {
+			return nil, err
+		}
+		onToken(response.Explanation)
+		return response, nil
+	}
+
+	messages, tools := fa.buildPrompt(userMessage)
+
+	result, err := streamingBackend.GenerateStream(context.Background(), messages, tools, func(chunk StreamChunk) {
+		if chunk.Content != "" {
+			onToken(chunk.Content)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fa.dispatch(userMessage, result), nil
+}
+
+// buildPrompt assembles the message history and tool schema shared by
+// ProcessUserRequest and ProcessUserRequestStream. When a Conversation is
+// attached, its windowed history is replayed ahead of the current message so
+// the model has memory of prior turns.
+func (fa *FreeAIAgent) buildPrompt(userMessage string) ([]Message, []Tool) {
 	systemInfo := fa.GetSystemInfo()
-	context := fmt.Sprintf("Current system: %s %s, macOS %s", 
+	sysContext := fmt.Sprintf("Current system: %s %s, macOS %s",
 		systemInfo["os"], systemInfo["arch"], systemInfo["macos_version"])
 
-	prompt := fmt.Sprintf(`%s
+	messages := []Message{
+		{Role: "system", Content: SystemPrompt},
+		{Role: "system", Content: "Context: " + sysContext},
+	}
 
-Context: %s
+	if fa.conversation != nil {
+		messages = append(messages, fa.conversation.Messages()...)
+	}
 
-User request: %s
+	messages = append(messages, Message{Role: "user", Content: userMessage})
 
-Please respond in valid JSON format with the following structure:
-{
-  "thoughts": "Your reasoning about what the user wants",
-  "commands": [
-    {
-      "command": "command_name",
-      "args": ["arg1", "arg2"],
-      "timeout": 30
-    }
-  ],
-  "explanation": "Explain what you're going to do and why",
-  "confidence": 0.95
-}`, SystemPrompt, context, userMessage)
+	return messages, commandsToTools(fa.policy.Commands)
+}
 
-	var response *FreeAIResponse
-	var err error
+// dispatch turns a backend Response into a FreeAIResponse, running any tool
+// calls the model made through ExecuteCommand, and records the turn to the
+// attached Conversation (if any).
+func (fa *FreeAIAgent) dispatch(userMessage string, result *Response) *FreeAIResponse {
+	response := &FreeAIResponse{
+		Explanation: result.Content,
+		Confidence:  1.0,
+		Usage:       result.Usage,
+	}
 
-	switch fa.serviceType {
-	case "ollama":
-		response, err = fa.callOllama(prompt)
-	case "huggingface":
-		response, err = fa.callHuggingFace(prompt)
-	case "local":
-		response, err = fa.callLocalAPI(prompt)
-	default:
-		return nil, fmt.Errorf("unsupported service type: %s", fa.serviceType)
+	if len(result.ToolCalls) == 0 && result.Content == "" {
+		response.Explanation = "Model returned an empty response"
+		response.Confidence = 0.0
+		response.Error = "Empty response from AI"
+		fa.recordTurn(userMessage, response, nil)
+		return response
 	}
 
-	if err != nil {
-		return nil, err
+	for _, call := range result.ToolCalls {
+		response.Commands = append(response.Commands, toolCallToCommandRequest(call))
 	}
 
 	if len(response.Commands) > 0 {
 		response.Results = make([]CommandResponse, 0, len(response.Commands))
-		
+
 		for _, cmd := range response.Commands {
-			result := fa.ExecuteCommand(cmd)
-			response.Results = append(response.Results, result)
+			response.Results = append(response.Results, fa.executeCommand(cmd, userMessage, fa.model))
 		}
 	}
 
-	return response, nil
+	fa.recordTurn(userMessage, response, response.Commands)
+	return response
+}
+
+// recordTurn appends the user prompt, assistant explanation, and any tool
+// results to the attached Conversation and persists it.
+func (fa *FreeAIAgent) recordTurn(userMessage string, response *FreeAIResponse, commands []CommandRequest) {
+	if fa.conversation == nil {
+		return
+	}
+
+	fa.conversation.AddUserTurn(userMessage)
+	fa.conversation.AddAssistantTurn(response.Explanation)
+
+	for i, cmd := range commands {
+		if i < len(response.Results) {
+			fa.conversation.AddToolResultTurn(cmd, response.Results[i])
+		}
+	}
+
+	if err := fa.conversation.Save(); err != nil {
+		fmt.Printf("Warning: failed to save session: %s\n", err)
+	}
+}
+
+// OllamaBackend talks to a local Ollama server's native /api/generate
+// endpoint, which has no function-calling support of its own. Tool calls are
+// requested via a JSON schema embedded in the prompt instead.
+type OllamaBackend struct {
+	baseURL string
+	model   string
+}
+
+func NewOllamaBackend(baseURL, model string) *OllamaBackend {
+	return &OllamaBackend{baseURL: baseURL, model: model}
 }
 
-func (fa *FreeAIAgent) callOllama(prompt string) (*FreeAIResponse, error) {
+func (o *OllamaBackend) Generate(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	prompt := buildToolPrompt(messages, tools)
+
 	requestBody := map[string]interface{}{
-		"model":  fa.model,
+		"model":  o.model,
 		"prompt": prompt,
 		"stream": false,
 	}
@@ -135,7 +225,13 @@ func (fa *FreeAIAgent) callOllama(prompt string) (*FreeAIResponse, error) {
 		return nil, err
 	}
 
-	resp, err := http.Post(fa.baseURL+"/api/generate", "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/api/generate", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("Ollama API error: %w", err)
 	}
@@ -147,8 +243,11 @@ func (fa *FreeAIAgent) callOllama(prompt string) (*FreeAIResponse, error) {
 	}
 
 	var ollamaResp struct {
-		Response string `json:"response"`
-		Error    string `json:"error,omitempty"`
+		Response        string `json:"response"`
+		Error           string `json:"error,omitempty"`
+		TotalDuration   int64  `json:"total_duration"`
+		PromptEvalCount int    `json:"prompt_eval_count"`
+		EvalCount       int    `json:"eval_count"`
 	}
 
 	if err := json.Unmarshal(body, &ollamaResp); err != nil {
@@ -159,21 +258,108 @@ func (fa *FreeAIAgent) callOllama(prompt string) (*FreeAIResponse, error) {
 		return nil, fmt.Errorf("Ollama error: %s", ollamaResp.Error)
 	}
 
-	var aiResponse FreeAIResponse
-	if err := json.Unmarshal([]byte(ollamaResp.Response), &aiResponse); err != nil {
-		aiResponse = FreeAIResponse{
-			Thoughts:    "Failed to parse AI response as JSON",
-			Commands:    []CommandRequest{},
-			Explanation: ollamaResp.Response,
-			Confidence:  0.0,
-			Error:       "Invalid JSON response from AI",
+	response := parseToolResponse(ollamaResp.Response)
+	response.Usage = &Usage{
+		PromptTokens:     ollamaResp.PromptEvalCount,
+		CompletionTokens: ollamaResp.EvalCount,
+		TotalTokens:      ollamaResp.PromptEvalCount + ollamaResp.EvalCount,
+		DurationMS:       ollamaResp.TotalDuration / int64(time.Millisecond),
+	}
+	return response, nil
+}
+
+// GenerateStream streams an Ollama generation by setting "stream": true and
+// reading the newline-delimited JSON response body one object at a time.
+func (o *OllamaBackend) GenerateStream(ctx context.Context, messages []Message, tools []Tool, onChunk func(StreamChunk)) (*Response, error) {
+	prompt := buildToolPrompt(messages, tools)
+
+	requestBody := map[string]interface{}{
+		"model":  o.model,
+		"prompt": prompt,
+		"stream": true,
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/api/generate", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Ollama API error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	streamer := newContentStreamer(onChunk)
+	var usage *Usage
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		var chunk struct {
+			Response        string `json:"response"`
+			Done            bool   `json:"done"`
+			Error           string `json:"error,omitempty"`
+			TotalDuration   int64  `json:"total_duration"`
+			PromptEvalCount int    `json:"prompt_eval_count"`
+			EvalCount       int    `json:"eval_count"`
+		}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return nil, fmt.Errorf("decoding Ollama stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("Ollama error: %s", chunk.Error)
 		}
+
+		streamer.feed(chunk.Response)
+
+		if chunk.Done {
+			usage = &Usage{
+				PromptTokens:     chunk.PromptEvalCount,
+				CompletionTokens: chunk.EvalCount,
+				TotalTokens:      chunk.PromptEvalCount + chunk.EvalCount,
+				DurationMS:       chunk.TotalDuration / int64(time.Millisecond),
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	response := parseToolResponse(streamer.finish())
+	response.Usage = usage
+	if usage != nil {
+		onChunk(StreamChunk{Done: true, Usage: usage})
 	}
 
-	return &aiResponse, nil
+	return response, nil
+}
+
+// HuggingFaceBackend talks to the Hugging Face Inference API, which (like
+// Ollama) has no native function-calling support.
+type HuggingFaceBackend struct {
+	baseURL string
+	apiKey  string
+	model   string
 }
 
-func (fa *FreeAIAgent) callHuggingFace(prompt string) (*FreeAIResponse, error) {
+func NewHuggingFaceBackend(baseURL, apiKey, model string) *HuggingFaceBackend {
+	return &HuggingFaceBackend{baseURL: baseURL, apiKey: apiKey, model: model}
+}
+
+func (hf *HuggingFaceBackend) Generate(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	prompt := buildToolPrompt(messages, tools)
+
 	requestBody := map[string]interface{}{
 		"inputs": prompt,
 		"parameters": map[string]interface{}{
@@ -187,12 +373,12 @@ func (fa *FreeAIAgent) callHuggingFace(prompt string) (*FreeAIResponse, error) {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", fa.baseURL+"/models/"+fa.model, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", hf.baseURL+"/models/"+hf.model, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+fa.apiKey)
+	req.Header.Set("Authorization", "Bearer "+hf.apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
 	client := &http.Client{Timeout: 30 * time.Second}
@@ -219,23 +405,26 @@ func (fa *FreeAIAgent) callHuggingFace(prompt string) (*FreeAIResponse, error) {
 		return nil, fmt.Errorf("no response from Hugging Face")
 	}
 
-	var aiResponse FreeAIResponse
-	if err := json.Unmarshal([]byte(hfResp[0].GeneratedText), &aiResponse); err != nil {
-		aiResponse = FreeAIResponse{
-			Thoughts:    "Failed to parse AI response as JSON",
-			Commands:    []CommandRequest{},
-			Explanation: hfResp[0].GeneratedText,
-			Confidence:  0.0,
-			Error:       "Invalid JSON response from AI",
-		}
-	}
+	return parseToolResponse(hfResp[0].GeneratedText), nil
+}
 
-	return &aiResponse, nil
+// LocalBackend talks to a bespoke local "/generate" style server that
+// predates the OpenAI-compatible client; kept for backends that don't speak
+// /v1/chat/completions.
+type LocalBackend struct {
+	baseURL string
+	model   string
 }
 
-func (fa *FreeAIAgent) callLocalAPI(prompt string) (*FreeAIResponse, error) {
+func NewLocalBackend(baseURL, model string) *LocalBackend {
+	return &LocalBackend{baseURL: baseURL, model: model}
+}
+
+func (l *LocalBackend) Generate(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	prompt := buildToolPrompt(messages, tools)
+
 	requestBody := map[string]interface{}{
-		"model":  fa.model,
+		"model":  l.model,
 		"prompt": prompt,
 	}
 
@@ -244,7 +433,13 @@ func (fa *FreeAIAgent) callLocalAPI(prompt string) (*FreeAIResponse, error) {
 		return nil, err
 	}
 
-	resp, err := http.Post(fa.baseURL+"/generate", "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", l.baseURL+"/generate", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("Local API error: %w", err)
 	}
@@ -256,8 +451,11 @@ func (fa *FreeAIAgent) callLocalAPI(prompt string) (*FreeAIResponse, error) {
 	}
 
 	var localResp struct {
-		Response string `json:"response"`
-		Error    string `json:"error,omitempty"`
+		Response         string `json:"response"`
+		Error            string `json:"error,omitempty"`
+		DurationMS       int64  `json:"duration_ms"`
+		PromptTokens     int    `json:"prompt_tokens"`
+		CompletionTokens int    `json:"completion_tokens"`
 	}
 
 	if err := json.Unmarshal(body, &localResp); err != nil {
@@ -268,18 +466,193 @@ func (fa *FreeAIAgent) callLocalAPI(prompt string) (*FreeAIResponse, error) {
 		return nil, fmt.Errorf("Local API error: %s", localResp.Error)
 	}
 
-	var aiResponse FreeAIResponse
-	if err := json.Unmarshal([]byte(localResp.Response), &aiResponse); err != nil {
-		aiResponse = FreeAIResponse{
-			Thoughts:    "Failed to parse AI response as JSON",
-			Commands:    []CommandRequest{},
-			Explanation: localResp.Response,
-			Confidence:  0.0,
-			Error:       "Invalid JSON response from AI",
+	response := parseToolResponse(localResp.Response)
+	response.Usage = &Usage{
+		PromptTokens:     localResp.PromptTokens,
+		CompletionTokens: localResp.CompletionTokens,
+		TotalTokens:      localResp.PromptTokens + localResp.CompletionTokens,
+		DurationMS:       localResp.DurationMS,
+	}
+	return response, nil
+}
+
+// GenerateStream streams a generation from the local server by setting
+// "stream": true and reading the newline-delimited JSON response body one
+// object at a time, mirroring OllamaBackend.GenerateStream.
+func (l *LocalBackend) GenerateStream(ctx context.Context, messages []Message, tools []Tool, onChunk func(StreamChunk)) (*Response, error) {
+	prompt := buildToolPrompt(messages, tools)
+
+	requestBody := map[string]interface{}{
+		"model":  l.model,
+		"prompt": prompt,
+		"stream": true,
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", l.baseURL+"/generate", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Local API error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	streamer := newContentStreamer(onChunk)
+	var usage *Usage
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		var chunk struct {
+			Response         string `json:"response"`
+			Done             bool   `json:"done"`
+			Error            string `json:"error,omitempty"`
+			DurationMS       int64  `json:"duration_ms"`
+			PromptTokens     int    `json:"prompt_tokens"`
+			CompletionTokens int    `json:"completion_tokens"`
+		}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return nil, fmt.Errorf("decoding local stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("Local API error: %s", chunk.Error)
+		}
+
+		streamer.feed(chunk.Response)
+
+		if chunk.Done {
+			usage = &Usage{
+				PromptTokens:     chunk.PromptTokens,
+				CompletionTokens: chunk.CompletionTokens,
+				TotalTokens:      chunk.PromptTokens + chunk.CompletionTokens,
+				DurationMS:       chunk.DurationMS,
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	response := parseToolResponse(streamer.finish())
+	response.Usage = usage
+	if usage != nil {
+		onChunk(StreamChunk{Done: true, Usage: usage})
+	}
+
+	return response, nil
+}
+
+// toolCallDelimiter separates the human-readable explanation from the
+// machine-readable tool calls in a prompt-only backend's reply. Keeping the
+// explanation as plain text rather than wrapping the whole reply in JSON
+// means it can be streamed to the user as-is instead of as partially-formed
+// JSON soup; only the trailing tool-call block needs to be withheld and
+// parsed.
+const toolCallDelimiter = "<<<TOOL_CALLS>>>"
+
+// buildToolPrompt renders the message history and tool schema into a single
+// prompt for backends with no native function-calling support.
+func buildToolPrompt(messages []Message, tools []Tool) string {
+	var b strings.Builder
+
+	for _, m := range messages {
+		fmt.Fprintf(&b, "%s: %s\n\n", m.Role, m.Content)
+	}
+
+	toolSchema, _ := json.MarshalIndent(tools, "", "  ")
+	fmt.Fprintf(&b, `Available tools (call the ones you need):
+%s
+
+Reply in plain text with your explanation of what you're doing. If you need
+to run any tools, end your reply with a line containing exactly %s followed
+by a JSON array of the tools to call, in this shape:
+[{"name": "tool_name", "args": {"args": ["arg1", "arg2"], "timeout": 30}}]
+If no tools are needed, write your explanation and nothing else.`, string(toolSchema), toolCallDelimiter)
+
+	return b.String()
+}
+
+// parseToolResponse splits a prompt-only backend's raw text reply on
+// toolCallDelimiter into its explanation and tool calls, falling back to
+// treating the whole reply as free-form content if the delimiter is absent
+// or what follows it isn't valid JSON.
+func parseToolResponse(raw string) *Response {
+	content, toolCallsJSON, found := strings.Cut(raw, toolCallDelimiter)
+	if !found {
+		return &Response{Content: strings.TrimSpace(raw)}
+	}
+
+	var calls []ToolCall
+	if err := json.Unmarshal([]byte(strings.TrimSpace(toolCallsJSON)), &calls); err != nil {
+		return &Response{Content: strings.TrimSpace(raw)}
+	}
+
+	return &Response{Content: strings.TrimSpace(content), ToolCalls: calls}
+}
+
+// contentStreamer incrementally reveals the human-readable explanation from
+// a prompt-only backend's raw stream, withholding text that might still turn
+// out to be (part of) toolCallDelimiter so the trailing tool-call JSON never
+// reaches onToken.
+type contentStreamer struct {
+	full     strings.Builder
+	revealed int
+	onChunk  func(StreamChunk)
+}
+
+func newContentStreamer(onChunk func(StreamChunk)) *contentStreamer {
+	return &contentStreamer{onChunk: onChunk}
+}
+
+// feed appends raw text from the backend and forwards any newly-confirmed
+// explanation text to onChunk.
+func (cs *contentStreamer) feed(text string) {
+	cs.full.WriteString(text)
+	full := cs.full.String()
+
+	if idx := strings.Index(full, toolCallDelimiter); idx != -1 {
+		if cs.revealed < idx {
+			cs.reveal(full[cs.revealed:idx])
 		}
+		cs.revealed = len(full)
+		return
+	}
+
+	// Hold back a tail one byte shorter than the delimiter in case it's a
+	// partial match straddling this chunk and the next one.
+	safeEnd := len(full) - (len(toolCallDelimiter) - 1)
+	if safeEnd > cs.revealed {
+		cs.reveal(full[cs.revealed:safeEnd])
+		cs.revealed = safeEnd
+	}
+}
+
+func (cs *contentStreamer) reveal(s string) {
+	if s != "" {
+		cs.onChunk(StreamChunk{Content: s})
 	}
+}
 
-	return &aiResponse, nil
+// finish reveals any text still withheld as a false-positive partial
+// delimiter match and returns the full raw response for parseToolResponse.
+func (cs *contentStreamer) finish() string {
+	full := cs.full.String()
+	if cs.revealed < len(full) {
+		cs.reveal(full[cs.revealed:])
+	}
+	return full
 }
 
 func (fa *FreeAIAgent) InteractiveFreeAIMode() {
@@ -307,27 +680,32 @@ func (fa *FreeAIAgent) InteractiveFreeAIMode() {
 			break
 		}
 
-		fmt.Println("Thinking...")
-		response, err := fa.ProcessUserRequest(userInput)
-		
+		fmt.Print("\n")
+		response, err := fa.ProcessUserRequestStream(userInput, func(token string) {
+			fmt.Print(token)
+		})
+
 		if err != nil {
 			fmt.Printf("Error: %s\n\n", err)
 			continue
 		}
 
-		fmt.Printf("\nThoughts: %s\n", response.Thoughts)
-		fmt.Printf("Explanation: %s\n", response.Explanation)
-		fmt.Printf("Confidence: %.1f%%\n", response.Confidence*100)
+		fmt.Printf("\nConfidence: %.1f%%\n", response.Confidence*100)
+		if response.Usage != nil {
+			fmt.Printf("Tokens: %d prompt + %d completion = %d total (%dms)\n",
+				response.Usage.PromptTokens, response.Usage.CompletionTokens,
+				response.Usage.TotalTokens, response.Usage.DurationMS)
+		}
 
 		if len(response.Commands) > 0 {
 			fmt.Printf("\nExecuting %d command(s):\n", len(response.Commands))
-			
+
 			for i, result := range response.Results {
-				fmt.Printf("\n--- Command %d: %s %s ---\n", 
+				fmt.Printf("\n--- Command %d: %s %s ---\n",
 					i+1, response.Commands[i].Command, strings.Join(response.Commands[i].Args, " "))
-				
+
 				if result.Success {
-					fmt.Printf("Success (%.2fms)\n", 
+					fmt.Printf("Success (%.2fms)\n",
 						parseDuration(result.Duration).Seconds()*1000)
 					if result.Output != "" {
 						fmt.Printf("Output:\n%s", result.Output)
@@ -359,4 +737,4 @@ func parseDuration(durationStr string) time.Duration {
 func (fa *FreeAIAgent) TestFreeAIConnection() error {
 	_, err := fa.ProcessUserRequest("test")
 	return err
-} 
\ No newline at end of file
+}
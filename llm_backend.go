@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// Message is a single turn in a conversation sent to an LLMBackend.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ToolParameterSchema describes the JSON schema for a tool's arguments,
+// following the shape OpenAI-compatible function-calling APIs expect.
+type ToolParameterSchema struct {
+	Type       string                  `json:"type"`
+	Properties map[string]ToolProperty `json:"properties,omitempty"`
+	Required   []string                `json:"required,omitempty"`
+}
+
+type ToolProperty struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tool is a single whitelisted command exposed to the model as something it
+// can call instead of free-texting a command line.
+type Tool struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Parameters  ToolParameterSchema `json:"parameters"`
+}
+
+// ToolCall is a structured invocation of a Tool returned by the model, as
+// opposed to the free-form JSON blob the old prompt-only flow relied on.
+type ToolCall struct {
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args"`
+}
+
+// Usage reports token accounting for a single Generate call, when the
+// backend's API exposes it.
+type Usage struct {
+	PromptTokens     int   `json:"prompt_tokens"`
+	CompletionTokens int   `json:"completion_tokens"`
+	TotalTokens      int   `json:"total_tokens"`
+	DurationMS       int64 `json:"duration_ms"`
+}
+
+// Response is what every LLMBackend returns: optional free-form content plus
+// zero or more structured tool calls the caller should dispatch.
+type Response struct {
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Usage     *Usage     `json:"usage,omitempty"`
+}
+
+// LLMBackend is implemented by every model provider FreeAIAgent can talk to.
+// Generate is given the running message history and the tool schema the
+// model is allowed to call, and returns either free-form content or
+// structured tool calls.
+type LLMBackend interface {
+	Generate(ctx context.Context, messages []Message, tools []Tool) (*Response, error)
+}
+
+// StreamChunk is one piece of a streamed Generate call: a fragment of
+// content, or (when Done is true) the final Usage accounting.
+type StreamChunk struct {
+	Content string
+	Done    bool
+	Usage   *Usage
+}
+
+// StreamingBackend is implemented by backends that can emit partial content
+// as it's generated instead of blocking until the full response is ready.
+// Not every LLMBackend supports this, so callers should type-assert for it
+// and fall back to plain Generate otherwise.
+type StreamingBackend interface {
+	LLMBackend
+	GenerateStream(ctx context.Context, messages []Message, tools []Tool, onChunk func(StreamChunk)) (*Response, error)
+}
+
+// commandsToTools converts the agent's policy-allowed commands into a
+// generic tool schema: an "args" array of strings plus an optional "timeout"
+// in seconds, since ExecuteCommand treats every whitelisted command the same
+// way regardless of its real argument shape.
+func commandsToTools(commands map[string]CommandPolicy) []Tool {
+	tools := make([]Tool, 0, len(commands))
+	for name := range commands {
+		tools = append(tools, Tool{
+			Name:        name,
+			Description: fmt.Sprintf("Run the '%s' command", name),
+			Parameters: ToolParameterSchema{
+				Type: "object",
+				Properties: map[string]ToolProperty{
+					"args": {
+						Type:        "array",
+						Description: "Command-line arguments to pass to " + name,
+					},
+					"timeout": {
+						Type:        "integer",
+						Description: "Timeout in seconds for this command",
+					},
+				},
+			},
+		})
+	}
+	return tools
+}
+
+// toolCallToCommandRequest converts a model-issued ToolCall into the
+// CommandRequest shape ExecuteCommand expects.
+func toolCallToCommandRequest(call ToolCall) CommandRequest {
+	req := CommandRequest{Command: call.Name}
+
+	if rawArgs, ok := call.Args["args"].([]interface{}); ok {
+		for _, a := range rawArgs {
+			if s, ok := a.(string); ok {
+				req.Args = append(req.Args, s)
+			}
+		}
+	}
+
+	if timeout, ok := call.Args["timeout"].(float64); ok {
+		req.Timeout = int(timeout)
+	}
+
+	return req
+}
@@ -5,62 +5,153 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type CommandRequest struct {
-	Command string `json:"command"`
-	Args    []string `json:"args,omitempty"`
-	Timeout int     `json:"timeout,omitempty"`
+	Command string      `json:"command"`
+	Args    []string    `json:"args,omitempty"`
+	Timeout int         `json:"timeout,omitempty"`
+	DryRun  bool        `json:"dry_run,omitempty"`
+	Sandbox SandboxMode `json:"sandbox,omitempty"`
 }
 
 type CommandResponse struct {
-	Success   bool   `json:"success"`
-	Output    string `json:"output"`
-	Error     string `json:"error,omitempty"`
-	ExitCode  int    `json:"exit_code"`
-	Duration  string `json:"duration"`
-	Timestamp string `json:"timestamp"`
+	Success     bool   `json:"success"`
+	Output      string `json:"output"`
+	Error       string `json:"error,omitempty"`
+	ExitCode    int    `json:"exit_code"`
+	Duration    string `json:"duration"`
+	Timestamp   string `json:"timestamp"`
+	MatchedRule string `json:"matched_rule,omitempty"`
+	DryRun      bool   `json:"dry_run,omitempty"`
+	Sandboxed   bool   `json:"sandboxed"`
 }
 
 type Agent struct {
-	allowedCommands map[string]bool
+	policy      *Policy
+	workingDir  string
+	auditLogger *AuditLogger
 }
 
 func NewAgent() *Agent {
-	allowedCommands := map[string]bool{
-		"ls": true, "pwd": true, "whoami": true, "date": true, "uptime": true,
-		"ps": true, "top": true, "df": true, "du": true, "find": true,
-		"grep": true, "cat": true, "head": true, "tail": true, "wc": true,
-		"sort": true, "uniq": true, "echo": true, "mkdir": true, "rmdir": true,
-		"cp": true, "mv": true, "rm": true, "chmod": true, "chown": true,
-		"file": true, "stat": true, "which": true, "whereis": true,
-		"system_profiler": true, "sw_vers": true, "defaults": true,
-		"launchctl": true, "netstat": true, "lsof": true, "ifconfig": true,
-		"ping": true, "nslookup": true, "dig": true, "curl": true, "wget": true,
+	return &Agent{
+		policy:      DefaultPolicy(),
+		workingDir:  sandboxWorkingDir(),
+		auditLogger: newDefaultAuditLogger(),
+	}
+}
+
+// NewAgentWithPolicy builds an Agent whose command rules are loaded from a
+// JSON policy file instead of the built-in default.
+func NewAgentWithPolicy(policyPath string) (*Agent, error) {
+	policy, err := LoadPolicy(policyPath)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Agent{
-		allowedCommands: allowedCommands,
+		policy:      policy,
+		workingDir:  sandboxWorkingDir(),
+		auditLogger: newDefaultAuditLogger(),
+	}, nil
+}
+
+// newAgentFromEnv builds an Agent from the policy file at MAC_AGENT_POLICY
+// if set, falling back to DefaultPolicy otherwise. It's the single place
+// every entry point (interactive mode, serve) constructs its Agent from, so
+// a custom policy file is picked up everywhere.
+func newAgentFromEnv() *Agent {
+	policyPath := os.Getenv("MAC_AGENT_POLICY")
+	if policyPath == "" {
+		return NewAgent()
 	}
+
+	agent, err := NewAgentWithPolicy(policyPath)
+	if err != nil {
+		log.Fatalf("Error loading policy file '%s': %s", policyPath, err)
+	}
+	return agent
+}
+
+// newDefaultAuditLogger opens the audit log, logging a warning and running
+// without an audit trail rather than failing agent construction if it can't
+// be opened.
+func newDefaultAuditLogger() *AuditLogger {
+	logger, err := NewAuditLogger()
+	if err != nil {
+		fmt.Printf("Warning: audit logging disabled: %s\n", err)
+		return nil
+	}
+	return logger
+}
+
+// sandboxWorkingDir is the directory commands run under SandboxRestricted
+// are confined to writing within: the process's current directory, or
+// os.TempDir() if the current directory can't be determined.
+func sandboxWorkingDir() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return os.TempDir()
+	}
+	return dir
 }
 
 func (a *Agent) ExecuteCommand(req CommandRequest) CommandResponse {
+	return a.executeCommand(req, "", "")
+}
+
+// executeCommand is ExecuteCommand plus the originating user prompt and
+// model name, which FreeAIAgent has and plain CommandRequest callers don't.
+// Both are recorded to the audit log alongside the request/response pair.
+func (a *Agent) executeCommand(req CommandRequest, userPrompt, model string) (response CommandResponse) {
 	start := time.Now()
-	
-	if !a.isCommandAllowed(req.Command) {
-		return CommandResponse{
-			Success:   false,
-			Output:    "",
-			Error:     fmt.Sprintf("Command '%s' is not allowed for security reasons", req.Command),
-			ExitCode:  -1,
-			Duration:  time.Since(start).String(),
-			Timestamp: time.Now().Format(time.RFC3339),
+
+	defer func() {
+		if a.auditLogger == nil {
+			return
+		}
+		if err := a.auditLogger.Log(userPrompt, model, req, response); err != nil {
+			fmt.Printf("Warning: failed to write audit log: %s\n", err)
+		}
+	}()
+
+	decision := a.policy.Evaluate(req)
+	if !decision.Allowed {
+		response = CommandResponse{
+			Success:     false,
+			Output:      "",
+			Error:       fmt.Sprintf("Command '%s' is not allowed: %s", req.Command, decision.Reason),
+			ExitCode:    -1,
+			Duration:    time.Since(start).String(),
+			Timestamp:   time.Now().Format(time.RFC3339),
+			MatchedRule: decision.Rule,
+		}
+		return
+	}
+
+	if req.DryRun {
+		args := ""
+		if len(req.Args) > 0 {
+			args = " " + strings.Join(req.Args, " ")
 		}
+		response = CommandResponse{
+			Success:     true,
+			Output:      fmt.Sprintf("Would run: %s%s", req.Command, args),
+			ExitCode:    0,
+			Duration:    time.Since(start).String(),
+			Timestamp:   time.Now().Format(time.RFC3339),
+			MatchedRule: decision.Rule,
+			DryRun:      true,
+		}
+		return
 	}
 
 	timeout := 30 * time.Second
@@ -71,28 +162,56 @@ func (a *Agent) ExecuteCommand(req CommandRequest) CommandResponse {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	var cmd *exec.Cmd
-	if len(req.Args) > 0 {
-		cmd = exec.CommandContext(ctx, req.Command, req.Args...)
-	} else {
-		cmd = exec.CommandContext(ctx, req.Command)
+	cmd, sandboxed, err := a.buildCommand(ctx, req)
+	if err != nil {
+		response = CommandResponse{
+			Success:     false,
+			Error:       err.Error(),
+			ExitCode:    -1,
+			Duration:    time.Since(start).String(),
+			Timestamp:   time.Now().Format(time.RFC3339),
+			MatchedRule: decision.Rule,
+		}
+		return
 	}
 
 	output, err := cmd.CombinedOutput()
-	
-	response := CommandResponse{
-		Success:   err == nil,
-		Output:    string(output),
-		ExitCode:  cmd.ProcessState.ExitCode(),
-		Duration:  time.Since(start).String(),
-		Timestamp: time.Now().Format(time.RFC3339),
+
+	response = CommandResponse{
+		Success:     err == nil,
+		Output:      string(output),
+		ExitCode:    cmd.ProcessState.ExitCode(),
+		Duration:    time.Since(start).String(),
+		Timestamp:   time.Now().Format(time.RFC3339),
+		MatchedRule: decision.Rule,
+		Sandboxed:   sandboxed,
 	}
 
 	if err != nil {
 		response.Error = err.Error()
 	}
 
-	return response
+	return
+}
+
+// buildCommand constructs the exec.Cmd to run req with, wrapping it in a
+// sandbox-exec profile when the request's sandbox mode calls for it and the
+// host supports sandbox-exec. Otherwise it falls back to running the
+// command directly, which is the only option off of macOS, warning that the
+// requested confinement wasn't applied so that isn't silent.
+func (a *Agent) buildCommand(ctx context.Context, req CommandRequest) (*exec.Cmd, bool, error) {
+	if req.sandboxMode() != SandboxOff {
+		if sandboxExecAvailable() {
+			cmd, err := buildSandboxedCommand(ctx, req, a.policy.Commands[req.Command], a.workingDir)
+			return cmd, true, err
+		}
+		fmt.Printf("Warning: sandbox mode %q requested for '%s' but sandbox-exec is unavailable; running unconfined\n", req.sandboxMode(), req.Command)
+	}
+
+	if len(req.Args) > 0 {
+		return exec.CommandContext(ctx, req.Command, req.Args...), false, nil
+	}
+	return exec.CommandContext(ctx, req.Command), false, nil
 }
 
 func (a *Agent) GetSystemInfo() map[string]interface{} {
@@ -114,15 +233,17 @@ func (a *Agent) GetSystemInfo() map[string]interface{} {
 	return info
 }
 
+// isCommandAllowed checks only the command name against the policy, with no
+// args, for callers that only need a coarse yes/no (e.g. listing
+// introspection). ExecuteCommand always evaluates the full CommandRequest.
 func (a *Agent) isCommandAllowed(command string) bool {
-	return a.allowedCommands[command]
+	return a.policy.Evaluate(CommandRequest{Command: command}).Allowed
 }
 
-func runFreeAI() {
-	if runtime.GOOS != "darwin" {
-		log.Fatal("This agent is designed to run on macOS only")
-	}
-
+// buildFreeAgent constructs the FreeAIAgent for whichever service is
+// configured via FREE_AI_SERVICE, shared by both the default chat flow and
+// the `session resume` flow.
+func buildFreeAgent() *FreeAIAgent {
 	serviceType := os.Getenv("FREE_AI_SERVICE")
 	if serviceType == "" {
 		serviceType = "ollama"
@@ -159,15 +280,37 @@ func runFreeAI() {
 			model = "default"
 		}
 		freeAgent = NewLocalAgent(baseURL, model)
-		
+
+	case "openai":
+		baseURL := os.Getenv("OPENAI_BASE_URL")
+		if baseURL == "" {
+			log.Fatal("OPENAI_BASE_URL environment variable is required for the openai service (e.g. an LM Studio, vLLM, or llama.cpp server URL)")
+		}
+		apiKey := os.Getenv("OPENAI_API_KEY")
+		model := os.Getenv("OPENAI_MODEL")
+		if model == "" {
+			model = "default"
+		}
+		freeAgent = NewOpenAICompatibleAgent(baseURL, apiKey, model)
+
 	default:
-		log.Fatalf("Unsupported free AI service: %s. Supported: ollama, huggingface, local", serviceType)
+		log.Fatalf("Unsupported free AI service: %s. Supported: ollama, huggingface, local, openai", serviceType)
+	}
+
+	return freeAgent
+}
+
+func runFreeAI() {
+	if runtime.GOOS != "darwin" {
+		log.Fatal("This agent is designed to run on macOS only")
 	}
 
+	freeAgent := buildFreeAgent()
+
 	if len(os.Args) > 2 {
 		userRequest := strings.Join(os.Args[2:], " ")
-		fmt.Printf("Processing request with %s: %s\n", serviceType, userRequest)
-		
+		fmt.Printf("Processing request with %s: %s\n", freeAgent.serviceType, userRequest)
+
 		response, err := freeAgent.ProcessUserRequest(userRequest)
 		if err != nil {
 			log.Fatalf("Error: %s", err)
@@ -176,11 +319,177 @@ func runFreeAI() {
 		responseJSON, _ := json.MarshalIndent(response, "", "  ")
 		fmt.Printf("Response: %s\n", string(responseJSON))
 	} else {
+		conv, err := NewConversation()
+		if err != nil {
+			log.Fatalf("Error starting session: %s", err)
+		}
+		freeAgent.AttachConversation(conv)
+
+		fmt.Printf("Session: %s\n", conv.ID)
 		freeAgent.InteractiveFreeAIMode()
 	}
 }
 
+// runSessionCommand handles the `mac-agent session list|resume <id>|new`
+// subcommands.
+func runSessionCommand(args []string) {
+	if len(args) == 0 {
+		log.Fatal("Usage: mac-agent session <list|resume|new> [id]")
+	}
+
+	switch args[0] {
+	case "list":
+		ids, err := ListConversations()
+		if err != nil {
+			log.Fatalf("Error listing sessions: %s", err)
+		}
+		for _, id := range ids {
+			fmt.Println(id)
+		}
+
+	case "new":
+		conv, err := NewConversation()
+		if err != nil {
+			log.Fatalf("Error creating session: %s", err)
+		}
+		fmt.Println(conv.ID)
+
+	case "resume":
+		if len(args) < 2 {
+			log.Fatal("Usage: mac-agent session resume <id>")
+		}
+
+		conv, err := LoadConversation(args[1])
+		if err != nil {
+			log.Fatalf("Error resuming session: %s", err)
+		}
+
+		freeAgent := buildFreeAgent()
+		freeAgent.AttachConversation(conv)
+
+		fmt.Printf("Session: %s\n", conv.ID)
+		freeAgent.InteractiveFreeAIMode()
+
+	default:
+		log.Fatalf("Unknown session subcommand: %s. Supported: list, resume, new", args[0])
+	}
+}
+
+// runServeCommand handles the `mac-agent serve [addr]` subcommand, exposing
+// the agent over HTTP instead of the interactive CLI.
+func runServeCommand(args []string) {
+	if runtime.GOOS != "darwin" {
+		log.Fatal("This agent is designed to run on macOS only")
+	}
+
+	addr := "127.0.0.1:8080"
+	if len(args) > 0 {
+		addr = args[0]
+	}
+
+	authToken := os.Getenv("MAC_AGENT_TOKEN")
+	if authToken == "" {
+		if !isLoopbackAddr(addr) {
+			log.Fatalf("Refusing to start: MAC_AGENT_TOKEN is not set and %s is not a loopback address. Set MAC_AGENT_TOKEN or bind to 127.0.0.1.", addr)
+		}
+		log.Println("Warning: MAC_AGENT_TOKEN is not set, the server will accept unauthenticated requests")
+	}
+
+	server := NewServer(newAgentFromEnv(), buildFreeAgent(), authToken)
+
+	log.Printf("mac-agent serving on %s", addr)
+	if err := http.ListenAndServe(addr, server.Handler()); err != nil {
+		log.Fatalf("Server error: %s", err)
+	}
+}
+
+// isLoopbackAddr reports whether addr (a "host:port" listen address) binds
+// only to the loopback interface, the one case where serving without an
+// auth token is tolerable.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if host == "" {
+		return false // e.g. ":8080" binds every interface
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// runAuditCommand handles the `mac-agent audit tail|grep|export` subcommands.
+func runAuditCommand(args []string) {
+	if len(args) == 0 {
+		log.Fatal("Usage: mac-agent audit <tail|grep|export> [args]")
+	}
+
+	logger, err := NewAuditLogger()
+	if err != nil {
+		log.Fatalf("Error opening audit log: %s", err)
+	}
+
+	switch args[0] {
+	case "tail":
+		n := 50
+		if len(args) > 1 {
+			if parsed, err := strconv.Atoi(args[1]); err == nil {
+				n = parsed
+			}
+		}
+		lines, err := logger.Tail(n)
+		if err != nil {
+			log.Fatalf("Error reading audit log: %s", err)
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+
+	case "grep":
+		if len(args) < 2 {
+			log.Fatal("Usage: mac-agent audit grep <pattern>")
+		}
+		lines, err := logger.Grep(args[1])
+		if err != nil {
+			log.Fatalf("Error searching audit log: %s", err)
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+
+	case "export":
+		if len(args) < 2 {
+			log.Fatal("Usage: mac-agent audit export <path>")
+		}
+		if err := logger.Export(args[1]); err != nil {
+			log.Fatalf("Error exporting audit log: %s", err)
+		}
+		fmt.Printf("Exported audit log to %s\n", args[1])
+
+	default:
+		log.Fatalf("Unknown audit subcommand: %s. Supported: tail, grep, export", args[0])
+	}
+}
+
 func main() {
 	_ = loadEnvFile(".env")
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "session":
+			runSessionCommand(os.Args[2:])
+			return
+		case "serve":
+			runServeCommand(os.Args[2:])
+			return
+		case "audit":
+			runAuditCommand(os.Args[2:])
+			return
+		}
+	}
+
 	runFreeAI()
 } 
\ No newline at end of file
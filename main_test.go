@@ -1,6 +1,8 @@
 package main
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -12,7 +14,7 @@ func TestNewAgent(t *testing.T) {
 		t.Fatal("Agent should not be nil")
 	}
 	
-	if len(agent.allowedCommands) == 0 {
+	if len(agent.policy.Commands) == 0 {
 		t.Error("Allowed commands should not be empty")
 	}
 }
@@ -118,4 +120,74 @@ func TestCommandResponseStructure(t *testing.T) {
 	if response.Output != "hello\n" {
 		t.Errorf("Expected output 'hello\\n', got '%s'", response.Output)
 	}
+}
+
+func TestSandboxDeniesWritesOutsideWorkingDir(t *testing.T) {
+	if !sandboxExecAvailable() {
+		t.Skip("sandbox-exec not available on this host")
+	}
+
+	agent := NewAgent()
+	req := CommandRequest{
+		Command: "rm",
+		Args:    []string{"/tmp/mac-agent-sandbox-test-should-not-exist"},
+		Sandbox: SandboxRestricted,
+	}
+
+	response := agent.ExecuteCommand(req)
+	if response.Success {
+		t.Error("Expected rm outside the sandbox working directory to fail")
+	}
+}
+
+func TestSandboxDeniesNetworkWhenCommandNotNetworkTagged(t *testing.T) {
+	if !sandboxExecAvailable() {
+		t.Skip("sandbox-exec not available on this host")
+	}
+
+	policy := DefaultPolicy()
+	curlPolicy := policy.Commands["curl"]
+	curlPolicy.Network = false
+	policy.Commands["curl"] = curlPolicy
+
+	agent := &Agent{policy: policy, workingDir: sandboxWorkingDir()}
+	req := CommandRequest{
+		Command: "curl",
+		Args:    []string{"-s", "-m", "3", "http://example.com"},
+		Sandbox: SandboxRestricted,
+	}
+
+	response := agent.ExecuteCommand(req)
+	if response.Success {
+		t.Error("Expected curl to fail when its policy entry is not network-tagged")
+	}
+}
+
+func TestAuditLoggerRedactsSecretValues(t *testing.T) {
+	logger := &AuditLogger{
+		path:     filepath.Join(t.TempDir(), "audit.log"),
+		redactor: buildRedactor([]string{"API_KEY=super-secret-value"}),
+	}
+
+	req := CommandRequest{Command: "echo", Args: []string{"super-secret-value"}}
+	resp := CommandResponse{Success: true, Output: "super-secret-value\n"}
+
+	if err := logger.Log("print my super-secret-value", "llama3.2", req, resp); err != nil {
+		t.Fatalf("Log returned error: %s", err)
+	}
+
+	lines, err := logger.Tail(1)
+	if err != nil {
+		t.Fatalf("Tail returned error: %s", err)
+	}
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 log line, got %d", len(lines))
+	}
+
+	if strings.Contains(lines[0], "super-secret-value") {
+		t.Errorf("expected secret value to be redacted, got: %s", lines[0])
+	}
+	if !strings.Contains(lines[0], "[REDACTED]") {
+		t.Errorf("expected redaction marker in log line, got: %s", lines[0])
+	}
 } 
\ No newline at end of file
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// OpenAIBackend talks to any server implementing the OpenAI
+// /v1/chat/completions API with function calling, which covers LocalAI,
+// llama.cpp server, vLLM, and LM Studio in addition to OpenAI itself.
+type OpenAIBackend struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+func NewOpenAIBackend(baseURL, apiKey, model string) *OpenAIBackend {
+	return &OpenAIBackend{
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		model:   model,
+		client:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+type openAIFunctionDef struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Parameters  ToolParameterSchema `json:"parameters"`
+}
+
+type openAITool struct {
+	Type     string             `json:"type"`
+	Function openAIFunctionDef  `json:"function"`
+}
+
+type openAIMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content,omitempty"`
+	ToolCalls []openAIToolCall `json:"tool_calls,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Tools    []openAITool    `json:"tools,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (o *OpenAIBackend) Generate(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	reqMessages := make([]openAIMessage, 0, len(messages))
+	for _, m := range messages {
+		reqMessages = append(reqMessages, openAIMessage{Role: m.Role, Content: m.Content})
+	}
+
+	reqTools := make([]openAITool, 0, len(tools))
+	for _, t := range tools {
+		reqTools = append(reqTools, openAITool{
+			Type: "function",
+			Function: openAIFunctionDef{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+
+	body, err := json.Marshal(openAIRequest{
+		Model:    o.model,
+		Messages: reqMessages,
+		Tools:    reqTools,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/v1/chat/completions", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI-compatible API error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var chatResp openAIChatResponse
+	if err := json.Unmarshal(respBody, &chatResp); err != nil {
+		return nil, fmt.Errorf("decoding OpenAI-compatible response: %w", err)
+	}
+
+	if chatResp.Error != nil {
+		return nil, fmt.Errorf("OpenAI-compatible API error: %s", chatResp.Error.Message)
+	}
+
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in OpenAI-compatible response")
+	}
+
+	message := chatResp.Choices[0].Message
+
+	response := &Response{
+		Content: message.Content,
+		Usage: &Usage{
+			PromptTokens:     chatResp.Usage.PromptTokens,
+			CompletionTokens: chatResp.Usage.CompletionTokens,
+			TotalTokens:      chatResp.Usage.TotalTokens,
+		},
+	}
+
+	for _, tc := range message.ToolCalls {
+		var args map[string]interface{}
+		if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+			return nil, fmt.Errorf("decoding tool call arguments for %s: %w", tc.Function.Name, err)
+		}
+		response.ToolCalls = append(response.ToolCalls, ToolCall{
+			Name: tc.Function.Name,
+			Args: args,
+		})
+	}
+
+	return response, nil
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CommandPolicy is the set of rules a single whitelisted command must
+// satisfy. All fields are optional; an empty CommandPolicy allows the
+// command with no further restriction beyond the global deny patterns.
+type CommandPolicy struct {
+	AllowedArgsPattern string   `json:"allowed_args_pattern,omitempty"`
+	DeniedArgsPatterns []string `json:"denied_args_patterns,omitempty"`
+	PathPrefixes       []string `json:"path_prefixes,omitempty"`
+	MaxRecursionDepth  int      `json:"max_recursion_depth,omitempty"`
+	AllowedHosts       []string `json:"allowed_hosts,omitempty"`
+	Network            bool     `json:"network,omitempty"`
+
+	allowedArgsRegexp *regexp.Regexp
+	deniedArgsRegexps []*regexp.Regexp
+}
+
+// Policy replaces the flat allowedCommands map with per-command rules plus
+// global deny patterns checked against every command regardless of which
+// rule matched it.
+type Policy struct {
+	Commands   map[string]CommandPolicy `json:"commands"`
+	GlobalDeny []string                 `json:"global_deny"`
+
+	globalDenyRegexps []*regexp.Regexp
+}
+
+// PolicyDecision is the result of evaluating a CommandRequest against a
+// Policy: whether it's allowed, which rule decided that, and why.
+type PolicyDecision struct {
+	Allowed bool   `json:"allowed"`
+	Rule    string `json:"rule"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// DefaultPolicy mirrors the command set the old flat allowlist shipped with,
+// with no extra per-command argument rules. Unlike the old allowlist, every
+// request is still checked against the global deny patterns below, and the
+// network tools are tagged Network for the sandbox.
+func DefaultPolicy() *Policy {
+	commands := []string{
+		"ls", "pwd", "whoami", "date", "uptime",
+		"ps", "top", "df", "du", "find",
+		"grep", "cat", "head", "tail", "wc",
+		"sort", "uniq", "echo", "mkdir", "rmdir",
+		"cp", "mv", "rm", "chmod", "chown",
+		"file", "stat", "which", "whereis",
+		"system_profiler", "sw_vers", "defaults",
+		"launchctl", "netstat", "lsof", "ifconfig",
+		"ping", "nslookup", "dig", "curl", "wget",
+	}
+
+	p := &Policy{
+		Commands: make(map[string]CommandPolicy, len(commands)),
+		GlobalDeny: []string{
+			`rm\s+-rf\s+/\s*$`,
+			"`",
+			`\$\(`,
+			`[;|&]`,
+			`>`,
+			`<`,
+		},
+	}
+
+	for _, cmd := range commands {
+		p.Commands[cmd] = CommandPolicy{}
+	}
+
+	for _, cmd := range []string{"curl", "wget", "ping", "nslookup", "dig"} {
+		cmdPolicy := p.Commands[cmd]
+		cmdPolicy.Network = true
+		p.Commands[cmd] = cmdPolicy
+	}
+
+	if err := p.compile(); err != nil {
+		panic(fmt.Sprintf("default policy failed to compile: %s", err))
+	}
+
+	return p
+}
+
+// LoadPolicy reads a Policy from a JSON file on disk. Command names not
+// present in the file are not allowed at all, matching the old allowlist's
+// default-deny behavior.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading policy file: %w", err)
+	}
+
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parsing policy file: %w", err)
+	}
+
+	if err := p.compile(); err != nil {
+		return nil, fmt.Errorf("compiling policy file: %w", err)
+	}
+
+	return &p, nil
+}
+
+// compile pre-compiles every regex in the policy once so Evaluate doesn't
+// re-parse patterns on every command.
+func (p *Policy) compile() error {
+	p.globalDenyRegexps = nil
+	for _, pattern := range p.GlobalDeny {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("global_deny pattern %q: %w", pattern, err)
+		}
+		p.globalDenyRegexps = append(p.globalDenyRegexps, re)
+	}
+
+	for name, cmdPolicy := range p.Commands {
+		if cmdPolicy.AllowedArgsPattern != "" {
+			re, err := regexp.Compile(cmdPolicy.AllowedArgsPattern)
+			if err != nil {
+				return fmt.Errorf("%s: allowed_args_pattern: %w", name, err)
+			}
+			cmdPolicy.allowedArgsRegexp = re
+		}
+
+		for _, pattern := range cmdPolicy.DeniedArgsPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: denied_args_patterns: %w", name, err)
+			}
+			cmdPolicy.deniedArgsRegexps = append(cmdPolicy.deniedArgsRegexps, re)
+		}
+
+		p.Commands[name] = cmdPolicy
+	}
+
+	return nil
+}
+
+// Evaluate checks a full CommandRequest - command and args together, not
+// just the command name - against the policy and returns which rule
+// decided the outcome.
+func (p *Policy) Evaluate(req CommandRequest) PolicyDecision {
+	full := req.Command
+	if len(req.Args) > 0 {
+		full += " " + strings.Join(req.Args, " ")
+	}
+
+	for i, re := range p.globalDenyRegexps {
+		if re.MatchString(full) {
+			return PolicyDecision{
+				Allowed: false,
+				Rule:    "global_deny[" + strconv.Itoa(i) + "]",
+				Reason:  fmt.Sprintf("command matches global deny pattern %q", p.GlobalDeny[i]),
+			}
+		}
+	}
+
+	cmdPolicy, ok := p.Commands[req.Command]
+	if !ok {
+		return PolicyDecision{
+			Allowed: false,
+			Rule:    "commands",
+			Reason:  fmt.Sprintf("command '%s' is not in the policy", req.Command),
+		}
+	}
+
+	for i, re := range cmdPolicy.deniedArgsRegexps {
+		for _, arg := range req.Args {
+			if re.MatchString(arg) {
+				return PolicyDecision{
+					Allowed: false,
+					Rule:    fmt.Sprintf("commands.%s.denied_args_patterns[%d]", req.Command, i),
+					Reason:  fmt.Sprintf("arg %q matches denied pattern %q", arg, cmdPolicy.DeniedArgsPatterns[i]),
+				}
+			}
+		}
+	}
+
+	if cmdPolicy.allowedArgsRegexp != nil {
+		for _, arg := range req.Args {
+			if !cmdPolicy.allowedArgsRegexp.MatchString(arg) {
+				return PolicyDecision{
+					Allowed: false,
+					Rule:    fmt.Sprintf("commands.%s.allowed_args_pattern", req.Command),
+					Reason:  fmt.Sprintf("arg %q does not match allowed pattern %q", arg, cmdPolicy.AllowedArgsPattern),
+				}
+			}
+		}
+	}
+
+	if len(cmdPolicy.PathPrefixes) > 0 {
+		if decision, denied := evaluatePathPrefixes(req, cmdPolicy); denied {
+			return decision
+		}
+	}
+
+	if req.Command == "find" && cmdPolicy.MaxRecursionDepth > 0 {
+		if decision, denied := evaluateMaxDepth(req, cmdPolicy); denied {
+			return decision
+		}
+	}
+
+	if len(cmdPolicy.AllowedHosts) > 0 {
+		if decision, denied := evaluateAllowedHosts(req, cmdPolicy); denied {
+			return decision
+		}
+	}
+
+	return PolicyDecision{Allowed: true, Rule: fmt.Sprintf("commands.%s", req.Command)}
+}
+
+// evaluatePathPrefixes restricts path-like args (anything not starting with
+// "-") to the command's configured path prefixes, e.g. `rm`/`mv`/`cp`
+// confined to a sandbox directory. Matching is on path boundaries, not raw
+// string prefixes, so a sibling directory that merely shares a prefix (e.g.
+// "/Users/tim/project-secrets" against prefix "/Users/tim/project") isn't
+// mistaken for a subpath.
+func evaluatePathPrefixes(req CommandRequest, cmdPolicy CommandPolicy) (PolicyDecision, bool) {
+	for _, arg := range req.Args {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		cleaned := filepath.Clean(arg)
+
+		allowed := false
+		for _, prefix := range cmdPolicy.PathPrefixes {
+			if pathWithinPrefix(cleaned, filepath.Clean(prefix)) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return PolicyDecision{
+				Allowed: false,
+				Rule:    fmt.Sprintf("commands.%s.path_prefixes", req.Command),
+				Reason:  fmt.Sprintf("path %q is outside the allowed prefixes %v", arg, cmdPolicy.PathPrefixes),
+			}, true
+		}
+	}
+
+	return PolicyDecision{}, false
+}
+
+// pathWithinPrefix reports whether path is prefix itself or a true subpath
+// of it, rejecting sibling paths that merely share a string prefix.
+func pathWithinPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+string(filepath.Separator))
+}
+
+// evaluateMaxDepth enforces a `find -maxdepth N` ceiling so an agent can't be
+// pointed at an unbounded recursive search.
+func evaluateMaxDepth(req CommandRequest, cmdPolicy CommandPolicy) (PolicyDecision, bool) {
+	for i, arg := range req.Args {
+		if arg != "-maxdepth" || i+1 >= len(req.Args) {
+			continue
+		}
+
+		depth, err := strconv.Atoi(req.Args[i+1])
+		if err != nil {
+			continue
+		}
+
+		if depth > cmdPolicy.MaxRecursionDepth {
+			return PolicyDecision{
+				Allowed: false,
+				Rule:    "commands.find.max_recursion_depth",
+				Reason:  fmt.Sprintf("-maxdepth %d exceeds the allowed maximum of %d", depth, cmdPolicy.MaxRecursionDepth),
+			}, true
+		}
+
+		return PolicyDecision{}, false
+	}
+
+	return PolicyDecision{
+		Allowed: false,
+		Rule:    "commands.find.max_recursion_depth",
+		Reason:  fmt.Sprintf("find requires an explicit -maxdepth <= %d", cmdPolicy.MaxRecursionDepth),
+	}, true
+}
+
+// evaluateAllowedHosts restricts network commands to a configured host
+// allowlist by matching the last non-flag argument, which is where
+// curl/wget/ping expect their target.
+func evaluateAllowedHosts(req CommandRequest, cmdPolicy CommandPolicy) (PolicyDecision, bool) {
+	var target string
+	for _, arg := range req.Args {
+		if !strings.HasPrefix(arg, "-") {
+			target = arg
+		}
+	}
+
+	if target == "" {
+		return PolicyDecision{
+			Allowed: false,
+			Rule:    fmt.Sprintf("commands.%s.allowed_hosts", req.Command),
+			Reason:  "no host argument found to check against the allowlist",
+		}, true
+	}
+
+	host := extractHost(target)
+
+	for _, allowed := range cmdPolicy.AllowedHosts {
+		if hostMatchesAllowed(host, allowed) {
+			return PolicyDecision{}, false
+		}
+	}
+
+	return PolicyDecision{
+		Allowed: false,
+		Rule:    fmt.Sprintf("commands.%s.allowed_hosts", req.Command),
+		Reason:  fmt.Sprintf("host %q is not in the allowed hosts %v", host, cmdPolicy.AllowedHosts),
+	}, true
+}
+
+// extractHost pulls the hostname out of target, which may be a bare
+// hostname (ping, nslookup, dig) or a full URL (curl, wget).
+func extractHost(target string) string {
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	return target
+}
+
+// hostMatchesAllowed reports whether host is exactly allowed or a true
+// subdomain of it, so "evil-example.com.attacker.net" can't pass an
+// allowlist entry of "example.com" on a bare substring match.
+func hostMatchesAllowed(host, allowed string) bool {
+	return host == allowed || strings.HasSuffix(host, "."+allowed)
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestEvaluatePathPrefixesRejectsSiblingDirectory(t *testing.T) {
+	cmdPolicy := CommandPolicy{PathPrefixes: []string{"/Users/tim/project"}}
+	req := CommandRequest{Command: "rm", Args: []string{"/Users/tim/project-secrets/file"}}
+
+	decision, denied := evaluatePathPrefixes(req, cmdPolicy)
+	if !denied {
+		t.Fatalf("expected sibling directory outside the prefix to be denied, got allowed: %+v", decision)
+	}
+}
+
+func TestEvaluatePathPrefixesAllowsTrueSubpath(t *testing.T) {
+	cmdPolicy := CommandPolicy{PathPrefixes: []string{"/Users/tim/project"}}
+	req := CommandRequest{Command: "rm", Args: []string{"/Users/tim/project/file"}}
+
+	if _, denied := evaluatePathPrefixes(req, cmdPolicy); denied {
+		t.Error("expected a true subpath of the prefix to be allowed")
+	}
+}
+
+func TestEvaluateAllowedHostsRejectsSuffixBypass(t *testing.T) {
+	cmdPolicy := CommandPolicy{AllowedHosts: []string{"example.com"}}
+	req := CommandRequest{Command: "curl", Args: []string{"http://evil-example.com.attacker.net"}}
+
+	decision, denied := evaluateAllowedHosts(req, cmdPolicy)
+	if !denied {
+		t.Fatalf("expected a host merely containing the allowed substring to be denied, got allowed: %+v", decision)
+	}
+}
+
+func TestEvaluateAllowedHostsAllowsExactAndSubdomain(t *testing.T) {
+	cmdPolicy := CommandPolicy{AllowedHosts: []string{"example.com"}}
+
+	for _, target := range []string{"http://example.com/path", "https://api.example.com"} {
+		req := CommandRequest{Command: "curl", Args: []string{target}}
+		if _, denied := evaluateAllowedHosts(req, cmdPolicy); denied {
+			t.Errorf("expected %q to be allowed", target)
+		}
+	}
+}
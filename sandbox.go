@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// SandboxMode controls how much of the filesystem and network a command
+// gets to see, enforced via macOS's sandbox-exec where available.
+type SandboxMode string
+
+const (
+	SandboxOff        SandboxMode = "off"
+	SandboxReadonly   SandboxMode = "readonly"
+	SandboxRestricted SandboxMode = "restricted"
+)
+
+// sandboxExecAvailable reports whether sandbox-exec can be used on this
+// host. It's only ever true on macOS.
+func sandboxExecAvailable() bool {
+	if runtime.GOOS != "darwin" {
+		return false
+	}
+	_, err := exec.LookPath("sandbox-exec")
+	return err == nil
+}
+
+// buildSandboxedCommand wraps req inside a generated sandbox-exec profile:
+// filesystem writes are confined to workDir (or denied entirely in
+// SandboxReadonly), network access is denied unless the command's policy
+// tags it as network-capable, and process-exec is restricted to the
+// whitelisted binary itself.
+func buildSandboxedCommand(ctx context.Context, req CommandRequest, cmdPolicy CommandPolicy, workDir string) (*exec.Cmd, error) {
+	binary, err := exec.LookPath(req.Command)
+	if err != nil {
+		return nil, fmt.Errorf("resolving path to '%s': %w", req.Command, err)
+	}
+
+	profile := sandboxProfile(binary, cmdPolicy, workDir, req.sandboxMode())
+
+	args := append([]string{"-p", profile, req.Command}, req.Args...)
+	return exec.CommandContext(ctx, "sandbox-exec", args...), nil
+}
+
+// sandboxProfile renders the Scheme sandbox profile passed to sandbox-exec
+// -p. See `man sandbox-exec` for the profile language.
+func sandboxProfile(binary string, cmdPolicy CommandPolicy, workDir string, mode SandboxMode) string {
+	profile := fmt.Sprintf(`(version 1)
+(deny default)
+(allow process-exec (literal %q))
+(allow file-read*)
+`, binary)
+
+	switch mode {
+	case SandboxReadonly:
+		// no file-write* rule: every write is denied
+	default: // SandboxRestricted
+		profile += fmt.Sprintf("(allow file-write* (subpath %q))\n", workDir)
+	}
+
+	if cmdPolicy.Network {
+		profile += "(allow network*)\n"
+	} else {
+		profile += "(deny network*)\n"
+	}
+
+	return profile
+}
+
+// sandboxMode returns the request's sandbox mode, defaulting to the safest
+// option (restricted) when unset.
+func (req CommandRequest) sandboxMode() SandboxMode {
+	if req.Sandbox == "" {
+		return SandboxRestricted
+	}
+	return SandboxMode(req.Sandbox)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// These exercise sandboxProfile directly, a pure string builder, so they run
+// everywhere regardless of whether sandbox-exec itself is installed (unlike
+// TestSandboxDeniesWritesOutsideWorkingDir/TestSandboxDeniesNetworkWhenCommandNotNetworkTagged
+// in main_test.go, which skip without it).
+func TestSandboxProfileRestrictedAllowsWritesUnderWorkDir(t *testing.T) {
+	profile := sandboxProfile("/bin/ls", CommandPolicy{}, "/tmp/work", SandboxRestricted)
+
+	if !strings.Contains(profile, `(allow file-write* (subpath "/tmp/work"))`) {
+		t.Errorf("expected restricted profile to allow writes under the working dir, got:\n%s", profile)
+	}
+}
+
+func TestSandboxProfileReadonlyDeniesAllWrites(t *testing.T) {
+	profile := sandboxProfile("/bin/ls", CommandPolicy{}, "/tmp/work", SandboxReadonly)
+
+	if strings.Contains(profile, "file-write*") {
+		t.Errorf("expected readonly profile to contain no file-write* allow rule, got:\n%s", profile)
+	}
+}
+
+func TestSandboxProfileDeniesNetworkByDefault(t *testing.T) {
+	profile := sandboxProfile("/usr/bin/curl", CommandPolicy{Network: false}, "/tmp/work", SandboxRestricted)
+
+	if !strings.Contains(profile, "(deny network*)") {
+		t.Errorf("expected non-network command's profile to deny network*, got:\n%s", profile)
+	}
+}
+
+func TestSandboxProfileAllowsNetworkWhenTagged(t *testing.T) {
+	profile := sandboxProfile("/usr/bin/curl", CommandPolicy{Network: true}, "/tmp/work", SandboxRestricted)
+
+	if !strings.Contains(profile, "(allow network*)") {
+		t.Errorf("expected network-tagged command's profile to allow network*, got:\n%s", profile)
+	}
+}
+
+func TestSandboxProfileRestrictsProcessExecToBinary(t *testing.T) {
+	profile := sandboxProfile("/bin/ls", CommandPolicy{}, "/tmp/work", SandboxRestricted)
+
+	if !strings.Contains(profile, `(allow process-exec (literal "/bin/ls"))`) {
+		t.Errorf("expected profile to restrict process-exec to the resolved binary, got:\n%s", profile)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Server exposes Agent and FreeAIAgent over HTTP so editors, Raycast,
+// Alfred, or a menubar app can drive the agent without spawning a new
+// process per query.
+type Server struct {
+	agent     *Agent
+	freeAgent *FreeAIAgent
+	authToken string
+}
+
+func NewServer(agent *Agent, freeAgent *FreeAIAgent, authToken string) *Server {
+	return &Server{
+		agent:     agent,
+		freeAgent: freeAgent,
+		authToken: authToken,
+	}
+}
+
+// Handler builds the HTTP handler for the server's endpoints, wrapped with
+// auth and request-logging middleware.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/execute", s.handleExecute)
+	mux.HandleFunc("/chat", s.handleChat)
+	mux.HandleFunc("/system", s.handleSystem)
+	mux.HandleFunc("/commands", s.handleCommands)
+
+	return s.logRequests(s.requireAuth(mux))
+}
+
+// requireAuth rejects requests without a matching "Authorization: Bearer
+// <token>" header. If no token is configured the server is left open, which
+// is only appropriate for local development.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.authToken == "" || constantTimeEqual(r.Header.Get("Authorization"), "Bearer "+s.authToken) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		writeCommandError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+	})
+}
+
+// constantTimeEqual compares two strings without leaking how many leading
+// bytes matched through response timing, which a plain "==" would for a
+// bearer token guarding shell execution.
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		log.Printf("%s %s %s", r.Method, r.URL.Path, time.Since(start))
+	})
+}
+
+// handleExecute runs a raw CommandRequest through the policy-gated Agent.
+func (s *Server) handleExecute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeCommandError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+
+	var req CommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeCommandError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.agent.ExecuteCommand(req))
+}
+
+type chatRequest struct {
+	Message string `json:"message"`
+}
+
+// handleChat runs a natural-language request through ProcessUserRequest.
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeCommandError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeCommandError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err))
+		return
+	}
+
+	response, err := s.freeAgent.ProcessUserRequest(req.Message)
+	if err != nil {
+		writeCommandError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleSystem reports the host's system info.
+func (s *Server) handleSystem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeCommandError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.agent.GetSystemInfo())
+}
+
+// handleCommands introspects the active policy so a client can discover
+// which commands and rules are in effect.
+func (s *Server) handleCommands(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeCommandError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.agent.policy)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("error encoding response: %s", err)
+	}
+}
+
+// writeCommandError writes a CommandResponse-shaped error so every endpoint,
+// not just /execute, returns errors in the same structure.
+func writeCommandError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, CommandResponse{
+		Success:  false,
+		Error:    message,
+		ExitCode: -1,
+	})
+}